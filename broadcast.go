@@ -1,16 +1,117 @@
 package broadcast
 
 import (
+	"context"
 	"runtime"
 	"sync"
+	"sync/atomic"
+	"time"
 )
 
-// Channel provides a broadcast channel semantics with closing of subscribers in case of back-pressure.
+// ChannelConfig configures the publish semantics of a Channel.
+// The zero value keeps the historical behaviour: Publish never blocks and
+// drops (closing) any subscriber that isn't immediately ready to receive.
+type ChannelConfig struct {
+	// BroadcastTimeout bounds how long Publish waits for a slow subscriber
+	// to accept a value before dropping it. Zero means don't wait at all,
+	// matching the original non-blocking drop behaviour. Ignored if
+	// Blocking is set.
+	BroadcastTimeout time.Duration
+	// Blocking makes Publish wait for every subscriber to receive the
+	// value, regardless of BroadcastTimeout. Subscribers are never dropped
+	// for being slow.
+	Blocking bool
+	// SubscriberBufferSize is the buffer size callers should use when
+	// creating channels to pass to Subscribe. It is not enforced by
+	// Channel itself, but documents the capacity this Channel was tuned
+	// for.
+	SubscriberBufferSize int
+}
+
+// listener is a single subscription registered with a Channel.
+//
+// closed is signalled to make a send to this listener that is currently in
+// flight (or starts afterwards) abort instead of completing; inflight
+// counts sends that have committed to using ch. Together they let closing
+// ch always make progress no matter what the subscriber is doing: nothing
+// ever waits for the subscriber to read, only for an in-flight send to
+// notice the signal and return, which it does as soon as it is scheduled.
+type listener[T any] struct {
+	ch       chan<- T
+	closed   chan struct{}
+	inflight atomic.Int32
+}
+
+// deactivate signals l so that any send to it already in flight, or
+// started afterwards, aborts instead of completing, then waits for every
+// such send to actually return. It is safe to call more than once.
+func (l *listener[T]) deactivate() {
+	select {
+	case <-l.closed:
+	default:
+		close(l.closed)
+	}
+	for l.inflight.Load() > 0 {
+		runtime.Gosched()
+	}
+}
+
+// Channel provides a broadcast channel semantics with closing of subscribers
+// in case of back-pressure.
+//
+// Publish is lock-free: it loads an immutable snapshot of the listener
+// slice through an atomic pointer and iterates it without taking any lock,
+// so publishing never serializes against concurrent Subscribe/Unsubscribe
+// calls, nor against other concurrent Publish calls. Subscribe, the
+// returned closer and Close are writers: they take writerLk and install a
+// new snapshot with copy-on-write. A subscriber that Publish couldn't
+// deliver to is recorded in a pending-removal set (also swapped in
+// lock-free via CAS) and is actually removed and closed the next time a
+// writer runs, or opportunistically by Publish itself if it can acquire
+// writerLk without blocking.
+//
+// Closing a listener's channel never races a Publish that might still be
+// sending to it, and crucially never waits on one either (this matters most
+// with ChannelConfig.Blocking, where a send can otherwise be in flight
+// forever): every send to a listener is bracketed by that listener's own
+// inflight counter, and removing a listener first signals its closed
+// channel, which makes any send to it currently in flight or started later
+// abort immediately, then waits for inflight to reach zero before closing
+// the channel. That wait only ever depends on an in-flight send noticing
+// the signal, never on the subscriber itself, so a stuck subscriber can
+// only ever delay closing its own listener, never an unrelated one, and
+// never another Publish call.
 type Channel[T any] struct {
-	lk        sync.Mutex
-	closed    bool
-	listeners []chan<- T
-	last      T
+	cfg ChannelConfig
+
+	writerLk sync.Mutex
+	closed   bool
+
+	listeners atomic.Pointer[[]*listener[T]]
+	pending   atomic.Pointer[[]*listener[T]]
+	last      atomic.Pointer[T]
+}
+
+// NewChannel creates a Channel using the given ChannelConfig. Use this
+// instead of the zero value when you need blocking or timeout-bounded
+// publish semantics.
+func NewChannel[T any](cfg ChannelConfig) *Channel[T] {
+	return &Channel[T]{cfg: cfg}
+}
+
+func (c *Channel[T]) loadListeners() []*listener[T] {
+	if p := c.listeners.Load(); p != nil {
+		return *p
+	}
+	return nil
+}
+
+func (c *Channel[T]) getLast() T {
+	if p := c.last.Load(); p != nil {
+		return *p
+	}
+	var zero T
+	return zero
 }
 
 // Subscribe is used to subscribe to the broadcast channel.
@@ -21,39 +122,50 @@ type Channel[T any] struct {
 // The default behaviour of subsciber after their channel gets closed should be to create a new
 // channel and attempt re-subscibing.
 func (c *Channel[T]) Subscribe(ch chan<- T) (last T, closer func()) {
-	c.lk.Lock()
-	defer c.lk.Unlock()
+	c.writerLk.Lock()
+	defer c.writerLk.Unlock()
 
 	if c.closed {
 		runtime.Gosched()
-		return c.last, func() {}
+		return c.getLast(), func() {}
 	}
 
-	for _, exCh := range c.listeners {
-		if exCh == ch {
+	c.drainPending()
+
+	cur := c.loadListeners()
+	for _, l := range cur {
+		if l.ch == ch {
 			panic("channel passed multiple times to Subscribe()")
 		}
 	}
-	c.listeners = append(c.listeners, ch)
-
-	return c.last, func() {
-		c.lk.Lock()
-		defer c.lk.Unlock()
-		for i, listener := range c.listeners {
-			if listener == ch {
-				// Remove the channel from the slice without preserving the order
-				c.listeners[i] = c.listeners[len(c.listeners)-1]
-				c.listeners = c.listeners[:len(c.listeners)-1]
-				close(listener)
-				return
-			}
-		}
+	l := &listener[T]{ch: ch, closed: make(chan struct{})}
+	next := make([]*listener[T], len(cur), len(cur)+1)
+	copy(next, cur)
+	next = append(next, l)
+	c.listeners.Store(&next)
+
+	return c.getLast(), func() {
+		c.writerLk.Lock()
+		defer c.writerLk.Unlock()
+		c.drainPending()
+		c.removeListenerLocked(l)
 	}
 }
 
+// SubscribeContext behaves like Subscribe, but also unsubscribes
+// automatically once ctx is done.
+func (c *Channel[T]) SubscribeContext(ctx context.Context, ch chan<- T) (last T, closer func()) {
+	last, closer = c.Subscribe(ch)
+	go func() {
+		<-ctx.Done()
+		closer()
+	}()
+	return last, closer
+}
+
 func (c *Channel[T]) IsClosed() bool {
-	c.lk.Lock()
-	defer c.lk.Unlock()
+	c.writerLk.Lock()
+	defer c.writerLk.Unlock()
 	return c.closed
 }
 
@@ -63,37 +175,148 @@ func (c *Channel[T]) IsClosed() bool {
 // The primary cause for this function is when the Channel that subscribers attempt to subscribe to
 // is getting swapped.
 func (c *Channel[T]) Close() {
-	c.lk.Lock()
-	defer c.lk.Unlock()
+	c.writerLk.Lock()
+	defer c.writerLk.Unlock()
+	c.drainPending()
 	c.closed = true
-	for _, listener := range c.listeners {
-		close(listener)
+	listeners := c.loadListeners()
+	c.listeners.Store(nil)
+
+	for _, l := range listeners {
+		l.deactivate()
+		close(l.ch)
 	}
-	c.listeners = nil
 }
 
 func (c *Channel[T]) Last() T {
-	c.lk.Lock()
-	defer c.lk.Unlock()
-	return c.last
+	return c.getLast()
 }
 
+// Publish delivers val to every listener without taking writerLk: it loads
+// the current listener snapshot through an atomic pointer and iterates it
+// directly, so it never blocks on, or is blocked by, Subscribe/Unsubscribe.
+// Each listener's inflight count is raised just before the send to it and
+// its closed signal is checked after that, so a listener being closed
+// concurrently is either skipped or waited for only until the send to it
+// notices the signal; a send stuck on one listener never holds up closing
+// another. A listener that can't be delivered to is added to the
+// pending-removal set instead of being removed in place, since mutating the
+// snapshot here would race with concurrent Publish calls; it gets removed
+// and closed on the next writer operation, or immediately below if
+// writerLk happens to be free.
 func (c *Channel[T]) Publish(val T) {
-	c.lk.Lock()
-	defer c.lk.Unlock()
-	for i := 0; i < len(c.listeners); {
-		ch := c.listeners[i]
+	v := val
+	c.last.Store(&v)
+
+	listeners := c.loadListeners()
+	for _, l := range listeners {
+		l.inflight.Add(1)
 		select {
-		case ch <- val:
-			i++
+		case <-l.closed:
+			// l is being removed and its channel may already be closed.
+			l.inflight.Add(-1)
+			continue
 		default:
-			close(ch)
-			// Replace the current channel with the last one and try again.
-			lastIdx := len(c.listeners) - 1
-			c.listeners[i], c.listeners[lastIdx] = c.listeners[lastIdx], nil
-			c.listeners = c.listeners[:lastIdx]
 		}
+		sent := c.send(l, val)
+		l.inflight.Add(-1)
+		if !sent {
+			c.addPending(l)
+		}
+	}
+
+	// Opportunistic drain: bounds the growth of the pending set when no
+	// Subscribe/Unsubscribe/Close call happens to be coming in.
+	if c.writerLk.TryLock() {
+		c.drainPending()
+		c.writerLk.Unlock()
 	}
+}
+
+// send delivers val to l according to the configured publish policy,
+// reporting whether it was delivered. l.closed is always one of the select
+// cases, so a listener being removed concurrently - including one stuck
+// here with ChannelConfig.Blocking and a subscriber that never reads - makes
+// this return immediately instead of waiting for the subscriber.
+func (c *Channel[T]) send(l *listener[T], val T) bool {
+	if c.cfg.Blocking {
+		select {
+		case l.ch <- val:
+			return true
+		case <-l.closed:
+			return false
+		}
+	}
+	if c.cfg.BroadcastTimeout > 0 {
+		timer := time.NewTimer(c.cfg.BroadcastTimeout)
+		defer timer.Stop()
+		select {
+		case l.ch <- val:
+			return true
+		case <-timer.C:
+			return false
+		case <-l.closed:
+			return false
+		}
+	}
+	select {
+	case l.ch <- val:
+		return true
+	case <-l.closed:
+		return false
+	default:
+		return false
+	}
+}
 
-	c.last = val
+// addPending records l as pending removal via a CAS loop, so it can be
+// called from Publish without taking writerLk.
+func (c *Channel[T]) addPending(l *listener[T]) {
+	for {
+		p := c.pending.Load()
+		var cur []*listener[T]
+		if p != nil {
+			cur = *p
+		}
+		next := make([]*listener[T], len(cur), len(cur)+1)
+		copy(next, cur)
+		next = append(next, l)
+		if c.pending.CompareAndSwap(p, &next) {
+			return
+		}
+	}
+}
+
+// drainPending removes and closes every listener queued up by Publish.
+// The caller must hold writerLk.
+func (c *Channel[T]) drainPending() {
+	p := c.pending.Swap(nil)
+	if p == nil {
+		return
+	}
+	for _, l := range *p {
+		c.removeListenerLocked(l)
+	}
+}
+
+// removeListenerLocked removes l from the listener snapshot and closes its
+// channel. If l is no longer present (e.g. it was already removed by a
+// concurrent drain or closer call), it is a no-op. The caller must hold
+// writerLk. l is deactivated before its channel is closed, so any send to
+// it already in flight is guaranteed to have returned first, regardless of
+// whether the subscriber ever reads.
+func (c *Channel[T]) removeListenerLocked(l *listener[T]) {
+	cur := c.loadListeners()
+	for i, cl := range cur {
+		if cl == l {
+			next := make([]*listener[T], len(cur)-1)
+			copy(next, cur[:i])
+			copy(next[i:], cur[i+1:])
+			c.listeners.Store(&next)
+
+			l.deactivate()
+			close(l.ch)
+			return
+		}
+	}
 }
@@ -0,0 +1,57 @@
+package broadcast
+
+import (
+	"testing"
+)
+
+// drain keeps reading from the buffered channels so that Publish/Send
+// mostly find room in them, keeping the fanout cost itself in focus. It
+// does not guarantee room: Send can still wait for a reader, and Publish
+// still drops (and closes) a listener whose buffer is full.
+func drain[T any](chans []chan T) (stop func()) {
+	done := make(chan struct{})
+	for _, c := range chans {
+		go func(c chan T) {
+			for {
+				select {
+				case <-c:
+				case <-done:
+					return
+				}
+			}
+		}(c)
+	}
+	return func() { close(done) }
+}
+
+func BenchmarkChannelPublish1000(b *testing.B) {
+	var ch Channel[int]
+	chans := make([]chan int, 1000)
+	for i := range chans {
+		chans[i] = make(chan int, 1)
+		ch.Subscribe(chans[i])
+	}
+	stop := drain(chans)
+	defer stop()
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		ch.Publish(i)
+	}
+}
+
+func BenchmarkFeedSend1000(b *testing.B) {
+	var feed Feed[int]
+	chans := make([]chan int, 1000)
+	for i := range chans {
+		chans[i] = make(chan int, 1)
+		feed.Subscribe(chans[i])
+	}
+	stop := drain(chans)
+	defer stop()
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		feed.Send(i)
+	}
+}
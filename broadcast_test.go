@@ -1,9 +1,11 @@
 package broadcast
 
 import (
+	"context"
 	"runtime"
 	"sync"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 )
@@ -132,6 +134,167 @@ func TestSubscribeWithLastValue(t *testing.T) {
 	assert.Equal(t, 42, last, "Expected last published value to be received")
 }
 
+func TestBlockingPublishWaitsForSlowSubscriber(t *testing.T) {
+	ch := NewChannel[int](ChannelConfig{Blocking: true})
+	c := make(chan int)
+
+	ch.Subscribe(c)
+
+	done := make(chan struct{})
+	go func() {
+		ch.Publish(42)
+		close(done)
+	}()
+
+	// Publish should still be waiting since nobody has read from c yet.
+	select {
+	case <-done:
+		t.Fatal("Expected blocking Publish to wait for the subscriber")
+	case <-time.After(20 * time.Millisecond):
+	}
+
+	assert.Equal(t, 42, <-c, "Expected published value to be received")
+	<-done
+}
+
+func TestBroadcastTimeoutDropsSlowSubscriber(t *testing.T) {
+	ch := NewChannel[int](ChannelConfig{BroadcastTimeout: 10 * time.Millisecond})
+	c := make(chan int)
+
+	ch.Subscribe(c)
+	ch.Publish(42)
+
+	_, ok := <-c
+	assert.False(t, ok, "Expected channel to be closed after the broadcast timeout elapsed")
+}
+
+func TestBroadcastTimeoutDeliversReadySubscriber(t *testing.T) {
+	ch := NewChannel[int](ChannelConfig{BroadcastTimeout: 50 * time.Millisecond})
+	c := make(chan int, 1)
+
+	ch.Subscribe(c)
+	ch.Publish(42)
+
+	assert.Equal(t, 42, <-c, "Expected published value to be received")
+}
+
+func TestBlockingPublishDoesNotRaceWithCloser(t *testing.T) {
+	ch := NewChannel[int](ChannelConfig{Blocking: true})
+	c := make(chan int) // unbuffered, so Publish blocks until read
+
+	_, closer := ch.Subscribe(c)
+
+	publishDone := make(chan struct{})
+	go func() {
+		ch.Publish(42)
+		close(publishDone)
+	}()
+
+	closerDone := make(chan struct{})
+	go func() {
+		// Give Publish time to deliver to the reader below before the
+		// closer runs.
+		time.Sleep(20 * time.Millisecond)
+		closer()
+		close(closerDone)
+	}()
+
+	// Reading the value lets the blocking Publish complete; the closer
+	// never waits for this read, since it aborts any send still in flight.
+	assert.Equal(t, 42, <-c, "Expected published value to be received")
+
+	<-publishDone
+	<-closerDone
+}
+
+func TestCloserMakesProgressWithoutSubscriberEverReading(t *testing.T) {
+	ch := NewChannel[int](ChannelConfig{Blocking: true})
+	c := make(chan int) // unbuffered, and nobody ever reads it
+
+	_, closer := ch.Subscribe(c)
+
+	go ch.Publish(42)
+
+	// Give Publish time to enter its blocking send against the unread
+	// channel before calling the closer.
+	time.Sleep(20 * time.Millisecond)
+
+	done := make(chan struct{})
+	go func() {
+		closer()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("Expected closer to return even though the subscriber never read")
+	}
+}
+
+func TestPublishNotBlockedByCloserQueuedBehindAnotherStuckPublish(t *testing.T) {
+	ch := NewChannel[int](ChannelConfig{Blocking: true})
+	c := make(chan int) // unbuffered, and nobody ever reads it
+
+	_, closer := ch.Subscribe(c)
+
+	go ch.Publish(1)
+
+	// Give the first Publish time to get stuck delivering to c.
+	time.Sleep(20 * time.Millisecond)
+
+	closerDone := make(chan struct{})
+	go func() {
+		// This races the stuck Publish above; it must not have to wait for
+		// it to finish.
+		closer()
+		close(closerDone)
+	}()
+
+	// Give the closer time to be "queued" the way the old RWMutex-based
+	// implementation would have queued it.
+	time.Sleep(20 * time.Millisecond)
+
+	secondDone := make(chan struct{})
+	go func() {
+		// An entirely separate Publish call must not be blocked just
+		// because a closer is in progress elsewhere on this Channel, even
+		// though the first Publish above is permanently stuck.
+		ch.Publish(2)
+		close(secondDone)
+	}()
+
+	select {
+	case <-secondDone:
+	case <-time.After(time.Second):
+		t.Fatal("Expected an unrelated Publish call to proceed despite another Publish being stuck and a closer in progress")
+	}
+
+	select {
+	case <-closerDone:
+	case <-time.After(time.Second):
+		t.Fatal("Expected closer to return despite racing a permanently stuck Publish")
+	}
+}
+
+func TestSubscribeContextUnsubscribesOnCancel(t *testing.T) {
+	var ch Channel[int]
+	c := make(chan int, 1)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	ch.SubscribeContext(ctx, c)
+	cancel()
+
+	assert.Eventually(t, func() bool {
+		select {
+		case _, ok := <-c:
+			return !ok
+		default:
+			return false
+		}
+	}, time.Second, time.Millisecond, "Expected channel to be closed once ctx is done")
+}
+
 func TestClose(t *testing.T) {
 	var ch Channel[int]
 	c1 := make(chan int, 1)
@@ -0,0 +1,424 @@
+package broadcast
+
+import (
+	"fmt"
+	"reflect"
+	"sync"
+)
+
+// Subscription represents a subscription created by Feed.Subscribe.
+// Unsubscribe must be called to release the subscription's resources.
+type Subscription interface {
+	// Unsubscribe stops delivery of values to the subscribed channel and
+	// closes the channel returned by Err.
+	Unsubscribe()
+	// Err returns a channel that is closed when the subscription ends,
+	// optionally carrying an error describing why it ended. It has a
+	// buffer of one, so ending a subscription never waits for a reader;
+	// once the error (if any) has been read, further reads see it closed.
+	Err() <-chan error
+}
+
+// Feed implements a one-to-many broadcaster of values of type T. A value
+// sent on the feed is delivered to every subscribed channel concurrently, so
+// a single slow subscriber cannot block delivery to the others. It is
+// modeled on go-ethereum's event.Feed, using a reflect-based dynamic select
+// to wait on however many subscriber channels happen to be active.
+//
+// The zero value is ready to use.
+type Feed[T any] struct {
+	once      sync.Once
+	sendLock  chan struct{} // sendLock has a one-element buffer and is empty when held
+	removeSub chan chan<- T // interrupts Send to remove a case
+	sendCases caseList      // the active set of select cases used by Send
+
+	mu       sync.Mutex
+	inbox    caseList                 // entries waiting to be added to sendCases
+	chanSubs map[chan<- T]*feedSub[T] // live subscriptions, keyed by their channel
+	closed   bool
+}
+
+// the first case in sendCases/inbox is always the removeSub recv case.
+const firstSubSendCase = 1
+
+type caseList []reflect.SelectCase
+
+func (f *Feed[T]) init() {
+	f.removeSub = make(chan chan<- T)
+	f.sendLock = make(chan struct{}, 1)
+	f.sendLock <- struct{}{}
+	f.sendCases = caseList{{Dir: reflect.SelectRecv, Chan: reflect.ValueOf(f.removeSub)}}
+}
+
+// Subscribe adds a channel to the feed. Future sends will be delivered on
+// the channel until the subscription is unsubscribed.
+//
+// The channel should have ample buffer space to avoid blocking other
+// subscribers; Feed never drops a subscriber for being slow.
+//
+// If the feed has already been closed, the returned Subscription is
+// terminated immediately: its Err() channel is already closed and channel
+// will never receive a value.
+func (f *Feed[T]) Subscribe(channel chan<- T) Subscription {
+	f.once.Do(f.init)
+
+	sub := &feedSub[T]{feed: f, channel: channel, err: make(chan error, 1)}
+
+	f.mu.Lock()
+	if f.closed {
+		f.mu.Unlock()
+		sub.errOnce.Do(func() { close(sub.err) })
+		return sub
+	}
+	if f.chanSubs == nil {
+		f.chanSubs = make(map[chan<- T]*feedSub[T])
+	}
+	f.chanSubs[channel] = sub
+	cas := reflect.SelectCase{Dir: reflect.SelectSend, Chan: reflect.ValueOf(channel)}
+	f.inbox = append(f.inbox, cas)
+	f.mu.Unlock()
+	return sub
+}
+
+func (f *Feed[T]) remove(sub *feedSub[T]) {
+	// Delete from inbox first, which covers channels that Send hasn't
+	// picked up yet.
+	f.mu.Lock()
+	delete(f.chanSubs, sub.channel)
+	index := f.inbox.find(sub.channel)
+	if index != -1 {
+		f.inbox = f.inbox.delete(index)
+		f.mu.Unlock()
+		return
+	}
+	f.mu.Unlock()
+
+	select {
+	case f.removeSub <- sub.channel:
+		// Send will remove the channel from sendCases.
+	case <-f.sendLock:
+		// No Send is in progress, remove the channel directly.
+		f.sendCases = f.sendCases.delete(f.sendCases.find(sub.channel))
+		f.sendLock <- struct{}{}
+	}
+}
+
+// Close ends the feed: every live subscription is terminated, delivering
+// err (if non-nil) on its Err() channel before that channel closes, exactly
+// as a panic during Send does for the one subscriber it affects. After
+// Close, Send becomes a no-op and Subscribe returns subscriptions that are
+// already terminated.
+func (f *Feed[T]) Close(err error) {
+	f.once.Do(f.init)
+
+	f.mu.Lock()
+	if f.closed {
+		f.mu.Unlock()
+		return
+	}
+	f.closed = true
+	subs := make([]*feedSub[T], 0, len(f.chanSubs))
+	for _, sub := range f.chanSubs {
+		subs = append(subs, sub)
+	}
+	f.mu.Unlock()
+
+	for _, sub := range subs {
+		sub.terminate(err)
+	}
+}
+
+// failedSend records a subscriber channel that panicked mid-delivery (most
+// plausibly because the subscriber closed it) along with the recovered
+// panic value, so it can be reported on that subscriber's Err() channel.
+type failedSend[T any] struct {
+	ch  chan<- T
+	err interface{}
+}
+
+// Send delivers val to all subscribed channels and returns the number of
+// subscribers that received it.
+func (f *Feed[T]) Send(val T) (nsent int) {
+	f.once.Do(f.init)
+	rvalue := reflect.ValueOf(val)
+
+	<-f.sendLock
+
+	f.mu.Lock()
+	f.sendCases = append(f.sendCases, f.inbox...)
+	f.inbox = nil
+	f.mu.Unlock()
+
+	for i := firstSubSendCase; i < len(f.sendCases); i++ {
+		f.sendCases[i].Send = rvalue
+	}
+
+	var failed []failedSend[T]
+
+	cases := f.sendCases
+	for {
+		// Fast path: try sending without blocking before the reflect-based
+		// select, so one slow subscriber doesn't force every delivery
+		// through the (more expensive) dynamic select below. A panic here
+		// (e.g. a subscriber closed its own channel) is attributed to that
+		// one subscriber via Err(); it is dropped and the rest proceed.
+		for i := firstSubSendCase; i < len(cases); i++ {
+			sent, alive, fs := f.trySend(cases[i], rvalue)
+			if !alive {
+				failed = append(failed, fs)
+				cases = cases.deactivate(i)
+				i--
+				continue
+			}
+			if sent {
+				nsent++
+				cases = cases.deactivate(i)
+				i--
+			}
+		}
+		if len(cases) == firstSubSendCase {
+			break
+		}
+
+		// Select on all the remaining subscriber sends plus removeSub, so
+		// an unsubscribe can interrupt a blocked delivery.
+		chosen, recv, alive := f.selectSend(cases)
+		if !alive {
+			// A subscriber channel was closed concurrently and the select
+			// panicked without telling us which case caused it; the next
+			// pass through the fast-path loop above will retry every
+			// remaining case individually and identify (and drop) exactly
+			// the offending one via trySend, so just retry.
+			continue
+		}
+		if chosen == 0 {
+			index := f.sendCases.find(recv.Interface())
+			f.sendCases = f.sendCases.delete(index)
+			if index >= 0 && index < len(cases) {
+				cases = f.sendCases[:len(cases)-1]
+			}
+		} else {
+			cases = cases.deactivate(chosen)
+			nsent++
+		}
+	}
+
+	// Permanently drop any subscriber whose channel panicked this round.
+	// This must happen before sendLock is released: f.sendCases is no
+	// longer being iterated at this point, but it would race a concurrent
+	// Send if we mutated it after giving up the lock.
+	for _, fs := range failed {
+		if index := f.sendCases.find(fs.ch); index != -1 {
+			f.sendCases = f.sendCases.delete(index)
+		}
+	}
+
+	// Forget about the value and drop the send lock.
+	for i := firstSubSendCase; i < len(f.sendCases); i++ {
+		f.sendCases[i].Send = reflect.Value{}
+	}
+	f.sendLock <- struct{}{}
+
+	// Surfacing the error on Err() runs after sendLock is released: it
+	// only touches chanSubs (guarded by its own mutex) and the
+	// subscription's own err channel, not anything Send needs exclusive
+	// access to.
+	for _, fs := range failed {
+		f.failSubscriber(fs.ch, fs.err)
+	}
+
+	return nsent
+}
+
+// trySend attempts a non-blocking send on cas, recovering from a panic (most
+// plausibly the subscriber closing its own channel). alive reports whether
+// cas is still usable; sent is only meaningful if alive is true. If alive is
+// false, fs identifies the failed channel and carries the recovered panic
+// value for failSubscriber to report later.
+func (f *Feed[T]) trySend(cas reflect.SelectCase, val reflect.Value) (sent, alive bool, fs failedSend[T]) {
+	defer func() {
+		if r := recover(); r != nil {
+			alive = false
+			fs.err = r
+			fs.ch, _ = cas.Chan.Interface().(chan<- T)
+		}
+	}()
+	return cas.Chan.TrySend(val), true, fs
+}
+
+// selectSend runs reflect.Select over cases, recovering from a panic so
+// that a single bad subscriber channel can't crash Send. alive reports
+// whether the select completed; chosen/recv are only meaningful if alive is
+// true. Unlike trySend, a panicking Select can't tell us which case caused
+// it, so the caller just retries through the fast path instead.
+func (f *Feed[T]) selectSend(cases caseList) (chosen int, recv reflect.Value, alive bool) {
+	defer func() {
+		if recover() != nil {
+			alive = false
+		}
+	}()
+	chosen, recv, _ = reflect.Select(cases)
+	return chosen, recv, true
+}
+
+// failSubscriber unregisters ch's subscription from the feed and surfaces r
+// as the error ending it, if the subscription is still live.
+func (f *Feed[T]) failSubscriber(ch chan<- T, r interface{}) {
+	if ch == nil {
+		return
+	}
+	f.mu.Lock()
+	sub := f.chanSubs[ch]
+	delete(f.chanSubs, ch)
+	f.mu.Unlock()
+
+	if sub != nil {
+		sub.deliverErr(fmt.Errorf("broadcast: panic delivering value to subscriber: %v", r))
+	}
+}
+
+func (cs caseList) find(channel interface{}) int {
+	for i, cas := range cs {
+		if cas.Chan.Interface() == channel {
+			return i
+		}
+	}
+	return -1
+}
+
+// delete removes the case at index from cs, preserving the order of the
+// remaining cases: Send relies on the cases it has already deactivated
+// staying at the tail. An index of -1 (find's result for a channel that is
+// no longer present) leaves cs unchanged. cs.find never matches the
+// reserved removeSub case in sendCases, so callers cannot accidentally
+// remove it.
+func (cs caseList) delete(index int) caseList {
+	if index < 0 {
+		return cs
+	}
+	return append(cs[:index], cs[index+1:]...)
+}
+
+// deactivate moves the case at index to the unused portion of the slice, so
+// it is not revisited in the same Send.
+func (cs caseList) deactivate(index int) caseList {
+	last := len(cs) - 1
+	cs[index], cs[last] = cs[last], cs[index]
+	return cs[:last]
+}
+
+type feedSub[T any] struct {
+	feed    *Feed[T]
+	channel chan<- T
+	errOnce sync.Once
+	err     chan error
+}
+
+func (sub *feedSub[T]) Unsubscribe() {
+	sub.terminate(nil)
+}
+
+func (sub *feedSub[T]) Err() <-chan error {
+	return sub.err
+}
+
+// terminate removes the subscription from the feed via the normal external
+// handshake (Feed.remove) and then ends it, exactly like deliverErr. Used
+// by Unsubscribe and Close. It must never be called from within Feed.Send
+// itself: Feed.remove can block waiting on Send's own select loop, which
+// would deadlock Send against itself; Send instead drops its bookkeeping
+// directly and calls deliverErr.
+func (sub *feedSub[T]) terminate(err error) {
+	sub.errOnce.Do(func() {
+		sub.feed.remove(sub)
+		sub.finish(err)
+	})
+}
+
+// deliverErr ends the subscription: if err is non-nil it is sent on Err()
+// before that channel is closed. It is safe to call concurrently and more
+// than once, including concurrently with terminate; only the first call to
+// either has any effect.
+func (sub *feedSub[T]) deliverErr(err error) {
+	sub.errOnce.Do(func() {
+		sub.finish(err)
+	})
+}
+
+// finish does the actual error delivery/close. The caller must be
+// inside sub.errOnce.Do.
+func (sub *feedSub[T]) finish(err error) {
+	if err != nil {
+		sub.err <- err
+	}
+	close(sub.err)
+}
+
+// SubscriptionScope provides a facility to unsubscribe multiple
+// subscriptions at once. Code that holds more than one subscription can use
+// a scope to conveniently unsubscribe all of them with a single call,
+// instead of hand-rolling closer bookkeeping. The zero value is ready to
+// use.
+type SubscriptionScope struct {
+	mu     sync.Mutex
+	subs   map[*scopeSub]struct{}
+	closed bool
+}
+
+type scopeSub struct {
+	sc *SubscriptionScope
+	s  Subscription
+}
+
+// Track starts tracking a subscription. If the scope is already closed,
+// Track unsubscribes s immediately and returns nil.
+func (sc *SubscriptionScope) Track(s Subscription) Subscription {
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+	if sc.closed {
+		s.Unsubscribe()
+		return nil
+	}
+	if sc.subs == nil {
+		sc.subs = make(map[*scopeSub]struct{})
+	}
+	ss := &scopeSub{sc, s}
+	sc.subs[ss] = struct{}{}
+	return ss
+}
+
+// Close calls Unsubscribe on all tracked subscriptions and prevents any
+// further subscriptions from being tracked.
+func (sc *SubscriptionScope) Close() {
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+	if sc.closed {
+		return
+	}
+	sc.closed = true
+	for s := range sc.subs {
+		s.s.Unsubscribe()
+	}
+	sc.subs = nil
+}
+
+// Count returns the number of tracked subscriptions. It is meant to be used
+// for debugging.
+func (sc *SubscriptionScope) Count() int {
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+	return len(sc.subs)
+}
+
+func (s *scopeSub) Unsubscribe() {
+	s.s.Unsubscribe()
+	s.sc.mu.Lock()
+	defer s.sc.mu.Unlock()
+	if !s.sc.closed {
+		delete(s.sc.subs, s)
+	}
+}
+
+func (s *scopeSub) Err() <-chan error {
+	return s.s.Err()
+}
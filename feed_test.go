@@ -0,0 +1,165 @@
+package broadcast
+
+import (
+	"errors"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFeedSubscribeAndSend(t *testing.T) {
+	var feed Feed[int]
+	c := make(chan int, 1)
+
+	feed.Subscribe(c)
+	n := feed.Send(42)
+
+	assert.Equal(t, 1, n, "Expected one subscriber to receive the value")
+	assert.Equal(t, 42, <-c, "Expected published value to be received")
+}
+
+func TestFeedSlowSubscriberDoesNotStarveOthers(t *testing.T) {
+	var feed Feed[int]
+	slow := make(chan int) // unbuffered, nobody reads until after Send returns
+	fast := make(chan int, 1)
+
+	feed.Subscribe(slow)
+	feed.Subscribe(fast)
+
+	done := make(chan int)
+	go func() { done <- feed.Send(42) }()
+
+	assert.Equal(t, 42, <-fast, "Expected the fast subscriber to receive promptly")
+	assert.Equal(t, 42, <-slow, "Expected the slow subscriber to eventually receive")
+	assert.Equal(t, 2, <-done, "Expected both subscribers to be counted")
+}
+
+func TestFeedUnsubscribe(t *testing.T) {
+	var feed Feed[int]
+	c := make(chan int, 1)
+
+	sub := feed.Subscribe(c)
+	sub.Unsubscribe()
+
+	_, open := <-sub.Err()
+	assert.False(t, open, "Expected Err() to close after Unsubscribe")
+
+	n := feed.Send(42)
+	assert.Equal(t, 0, n, "Expected no subscribers to receive after unsubscribe")
+}
+
+func TestSubscriptionScopeClose(t *testing.T) {
+	var feed Feed[int]
+	var scope SubscriptionScope
+	c1 := make(chan int, 1)
+	c2 := make(chan int, 1)
+
+	scope.Track(feed.Subscribe(c1))
+	scope.Track(feed.Subscribe(c2))
+	assert.Equal(t, 2, scope.Count())
+
+	scope.Close()
+	assert.Equal(t, 0, scope.Count())
+
+	n := feed.Send(42)
+	assert.Equal(t, 0, n, "Expected no subscribers to receive after scope close")
+}
+
+func TestSubscriptionScopeTrackAfterClose(t *testing.T) {
+	var feed Feed[int]
+	var scope SubscriptionScope
+	scope.Close()
+
+	c := make(chan int, 1)
+	sub := scope.Track(feed.Subscribe(c))
+	assert.Nil(t, sub, "Expected Track on a closed scope to return nil")
+
+	n := feed.Send(42)
+	assert.Equal(t, 0, n, "Expected subscription added to a closed scope to be unsubscribed immediately")
+}
+
+func TestFeedCloseDeliversError(t *testing.T) {
+	var feed Feed[int]
+	c := make(chan int, 1)
+
+	sub := feed.Subscribe(c)
+	wantErr := errors.New("feed shutting down")
+	feed.Close(wantErr)
+
+	err, open := <-sub.Err()
+	assert.True(t, open, "Expected Err() to carry a value before closing")
+	assert.Equal(t, wantErr, err)
+
+	_, open = <-sub.Err()
+	assert.False(t, open, "Expected Err() to close after the error")
+}
+
+func TestFeedCloseWithoutErrorJustEndsSubscriptions(t *testing.T) {
+	var feed Feed[int]
+	c := make(chan int, 1)
+
+	sub := feed.Subscribe(c)
+	feed.Close(nil)
+
+	_, open := <-sub.Err()
+	assert.False(t, open, "Expected Err() to close without carrying a value")
+}
+
+func TestFeedSubscribeAfterCloseIsTerminated(t *testing.T) {
+	var feed Feed[int]
+	feed.Close(nil)
+
+	c := make(chan int, 1)
+	sub := feed.Subscribe(c)
+
+	_, open := <-sub.Err()
+	assert.False(t, open, "Expected a post-Close subscription to already be terminated")
+
+	n := feed.Send(42)
+	assert.Equal(t, 0, n, "Expected no subscribers to receive after Close")
+}
+
+func TestFeedSendSurfacesPanicFromClosedSubscriberChannel(t *testing.T) {
+	var feed Feed[int]
+	bad := make(chan int, 1)
+	good := make(chan int, 1)
+
+	sub := feed.Subscribe(bad)
+	feed.Subscribe(good)
+	close(bad)
+
+	n := feed.Send(42)
+	assert.Equal(t, 1, n, "Expected only the live subscriber to be counted")
+	assert.Equal(t, 42, <-good)
+
+	err, open := <-sub.Err()
+	assert.True(t, open, "Expected the closed subscriber's Err() to carry a value")
+	assert.Error(t, err)
+
+	// The feed must have dropped the subscription: a second Send should not
+	// try (and panic) on the same closed channel again.
+	assert.NotPanics(t, func() { feed.Send(43) })
+}
+
+func TestFeedConcurrentSend(t *testing.T) {
+	var feed Feed[int]
+	const subs = 10
+	chans := make([]chan int, subs)
+	for i := range chans {
+		chans[i] = make(chan int, 1)
+		feed.Subscribe(chans[i])
+	}
+
+	wg := &sync.WaitGroup{}
+	wg.Add(subs)
+	for _, c := range chans {
+		go func(c chan int) {
+			defer wg.Done()
+			assert.Equal(t, 42, <-c)
+		}(c)
+	}
+
+	feed.Send(42)
+	wg.Wait()
+}
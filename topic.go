@@ -0,0 +1,162 @@
+package broadcast
+
+import (
+	"context"
+	"runtime"
+	"sync"
+)
+
+// TopicChannel provides broadcast channel semantics keyed by an arbitrary
+// comparable key: subscribers register interest in one or more keys and only
+// receive values published under those keys. It lets callers replace a
+// hand-rolled map[K]*Channel[T] (and the per-key cleanup that comes with it)
+// with a single structure, modeled on the bitswap notifications pattern of
+// subscribing to a set of keys and auto-unsubscribing on cancellation.
+//
+// The zero value is ready to use.
+type TopicChannel[K comparable, T any] struct {
+	lk     sync.Mutex
+	closed bool
+
+	byKey  map[K][]chan<- T
+	byChan map[chan<- T][]K
+	last   map[K]T
+}
+
+// Subscribe registers ch to receive values published under any of keys.
+// If ch is already subscribed, Subscribe panics.
+// For every key that has already been published to, the last published
+// value is returned in last.
+// To stop subscribing, either call the closer function or abandon the
+// channel; like Channel, a subscriber that is slow to receive gets dropped
+// and closed on the next Publish for one of its keys.
+func (t *TopicChannel[K, T]) Subscribe(ch chan<- T, keys ...K) (last map[K]T, closer func()) {
+	t.lk.Lock()
+	defer t.lk.Unlock()
+
+	if t.closed {
+		runtime.Gosched()
+		return nil, func() {}
+	}
+
+	if _, exists := t.byChan[ch]; exists {
+		panic("channel passed multiple times to Subscribe()")
+	}
+
+	if t.byKey == nil {
+		t.byKey = make(map[K][]chan<- T)
+		t.byChan = make(map[chan<- T][]K)
+	}
+	if t.last == nil {
+		t.last = make(map[K]T)
+	}
+
+	t.byChan[ch] = append([]K(nil), keys...)
+	for _, k := range keys {
+		t.byKey[k] = append(t.byKey[k], ch)
+	}
+
+	if len(keys) > 0 {
+		last = make(map[K]T, len(keys))
+		for _, k := range keys {
+			if v, ok := t.last[k]; ok {
+				last[k] = v
+			}
+		}
+	}
+
+	return last, func() {
+		t.lk.Lock()
+		defer t.lk.Unlock()
+		t.removeLocked(ch)
+	}
+}
+
+// SubscribeContext behaves like Subscribe, but also unsubscribes
+// automatically once ctx is done.
+func (t *TopicChannel[K, T]) SubscribeContext(ctx context.Context, ch chan<- T, keys ...K) (last map[K]T, closer func()) {
+	last, closer = t.Subscribe(ch, keys...)
+	go func() {
+		<-ctx.Done()
+		closer()
+	}()
+	return last, closer
+}
+
+// removeLocked unsubscribes ch from every key it was registered for and
+// closes it. The caller holds t.lk.
+func (t *TopicChannel[K, T]) removeLocked(ch chan<- T) {
+	keys, ok := t.byChan[ch]
+	if !ok {
+		return
+	}
+	delete(t.byChan, ch)
+	for _, k := range keys {
+		listeners := t.byKey[k]
+		for i, l := range listeners {
+			if l == ch {
+				listeners[i] = listeners[len(listeners)-1]
+				listeners = listeners[:len(listeners)-1]
+				break
+			}
+		}
+		if len(listeners) == 0 {
+			delete(t.byKey, k)
+		} else {
+			t.byKey[k] = listeners
+		}
+	}
+	close(ch)
+}
+
+// Last returns the last value published under key, if any.
+func (t *TopicChannel[K, T]) Last(key K) T {
+	t.lk.Lock()
+	defer t.lk.Unlock()
+	return t.last[key]
+}
+
+// IsClosed reports whether Close has been called.
+func (t *TopicChannel[K, T]) IsClosed() bool {
+	t.lk.Lock()
+	defer t.lk.Unlock()
+	return t.closed
+}
+
+// Publish delivers val to every subscriber registered for key. Subscribers
+// whose channel is full are dropped and closed, just like Channel.Publish.
+func (t *TopicChannel[K, T]) Publish(key K, val T) {
+	t.lk.Lock()
+	defer t.lk.Unlock()
+
+	if t.last == nil {
+		t.last = make(map[K]T)
+	}
+	t.last[key] = val
+
+	listeners := append([]chan<- T(nil), t.byKey[key]...)
+	var dropped []chan<- T
+	for _, ch := range listeners {
+		select {
+		case ch <- val:
+		default:
+			dropped = append(dropped, ch)
+		}
+	}
+	for _, ch := range dropped {
+		t.removeLocked(ch)
+	}
+}
+
+// Close closes the TopicChannel, closing every subscribed channel regardless
+// of which keys it was registered for.
+func (t *TopicChannel[K, T]) Close() {
+	t.lk.Lock()
+	defer t.lk.Unlock()
+	t.closed = true
+	for ch := range t.byChan {
+		close(ch)
+	}
+	t.byKey = nil
+	t.byChan = nil
+}
@@ -0,0 +1,134 @@
+package broadcast
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTopicChannelPublishToSubscribedKey(t *testing.T) {
+	var tc TopicChannel[string, int]
+	c := make(chan int, 1)
+
+	tc.Subscribe(c, "a")
+	tc.Publish("a", 42)
+	tc.Publish("b", 7)
+
+	assert.Equal(t, 42, <-c, "Expected value published to a subscribed key")
+	select {
+	case v := <-c:
+		t.Fatalf("Expected no value for unsubscribed key, got %d", v)
+	default:
+	}
+}
+
+func TestTopicChannelSubscribeMultipleKeys(t *testing.T) {
+	var tc TopicChannel[string, int]
+	c := make(chan int, 2)
+
+	tc.Subscribe(c, "a", "b")
+	tc.Publish("a", 1)
+	tc.Publish("b", 2)
+
+	assert.Equal(t, 1, <-c)
+	assert.Equal(t, 2, <-c)
+}
+
+func TestTopicChannelCloserUnsubscribesAllKeys(t *testing.T) {
+	var tc TopicChannel[string, int]
+	c := make(chan int, 2)
+
+	_, closer := tc.Subscribe(c, "a", "b")
+	closer()
+
+	_, ok := <-c
+	assert.False(t, ok, "Expected channel to be closed")
+
+	// Publishing after the closer ran must not panic or resurrect the
+	// subscription.
+	tc.Publish("a", 1)
+	tc.Publish("b", 2)
+}
+
+func TestTopicChannelLastValuePerKey(t *testing.T) {
+	var tc TopicChannel[string, int]
+
+	tc.Publish("a", 42)
+	assert.Equal(t, 42, tc.Last("a"))
+	assert.Zero(t, tc.Last("b"), "Expected zero value for a key that was never published")
+
+	last, _ := tc.Subscribe(make(chan int), "a", "b")
+	assert.Equal(t, 42, last["a"], "Expected Subscribe to return the last value for 'a'")
+	_, hasB := last["b"]
+	assert.False(t, hasB, "Expected no last value recorded for 'b'")
+}
+
+func TestTopicChannelBackPressureDropsOnlyThatSubscriber(t *testing.T) {
+	var tc TopicChannel[string, int]
+	slow := make(chan int) // unbuffered, never read
+	fast := make(chan int, 1)
+
+	tc.Subscribe(slow, "a")
+	tc.Subscribe(fast, "a")
+
+	tc.Publish("a", 42)
+
+	assert.Equal(t, 42, <-fast, "Expected the fast subscriber to receive the value")
+	_, ok := <-slow
+	assert.False(t, ok, "Expected the slow subscriber to be dropped and closed")
+}
+
+func TestTopicChannelSubscribeContextUnsubscribesOnCancel(t *testing.T) {
+	var tc TopicChannel[string, int]
+	c := make(chan int, 1)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	tc.SubscribeContext(ctx, c, "a")
+	cancel()
+
+	assert.Eventually(t, func() bool {
+		select {
+		case _, ok := <-c:
+			return !ok
+		default:
+			return false
+		}
+	}, time.Second, time.Millisecond, "Expected channel to be closed once ctx is done")
+}
+
+func TestTopicChannelClose(t *testing.T) {
+	var tc TopicChannel[string, int]
+	c1 := make(chan int, 1)
+	c2 := make(chan int, 1)
+
+	tc.Subscribe(c1, "a")
+	tc.Subscribe(c2, "b")
+	tc.Close()
+
+	_, ok1 := <-c1
+	_, ok2 := <-c2
+	assert.False(t, ok1)
+	assert.False(t, ok2)
+	assert.True(t, tc.IsClosed())
+}
+
+func TestTopicChannelMultipleSubscribers(t *testing.T) {
+	var tc TopicChannel[string, int]
+	wg := &sync.WaitGroup{}
+
+	for i := 0; i < 5; i++ {
+		c := make(chan int, 1)
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			assert.Equal(t, 42, <-c)
+		}()
+		tc.Subscribe(c, "a")
+	}
+
+	tc.Publish("a", 42)
+	wg.Wait()
+}